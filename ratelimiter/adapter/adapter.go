@@ -0,0 +1,32 @@
+package adapter
+
+// LimitResult describes the outcome of a single token-bucket take
+// operation, with enough detail for a middleware to surface RFC-6585/IETF
+// RateLimit-* headers back to the client.
+type LimitResult struct {
+	Allowed bool
+	// Remaining is the number of tokens left in the bucket after this
+	// take, floored to an integer for header purposes.
+	Remaining int64
+	// ResetMs is how long, in milliseconds, until Remaining would next
+	// increase - or, once blocked, until the block itself is lifted.
+	ResetMs int64
+	// Limit is the bucket's capacity (BurstSize when set, otherwise
+	// MaxRequestsPerSecond).
+	Limit int64
+	// Smoothed is true when the request was allowed only because it dipped
+	// into burst capacity rather than the steady refill rate.
+	Smoothed bool
+}
+
+// RateLimitStorageAdapter is implemented by the storage backends used to
+// keep track of rate limit counters (e.g. in-memory or Redis).
+type RateLimitStorageAdapter interface {
+	// Take attempts to take a single token from the bucket identified by
+	// key. maxRequestsPerSecond is the steady refill rate and burstSize is
+	// the maximum number of tokens the bucket can accumulate. When
+	// burstSize is 0 the bucket behaves like a plain fixed-window limiter:
+	// its capacity is maxRequestsPerSecond and, once exhausted, it stays
+	// blocked for blockTimeMilliseconds.
+	Take(key string, maxRequestsPerSecond int64, burstSize int64, blockTimeMilliseconds int64) (*LimitResult, error)
+}
@@ -0,0 +1,93 @@
+package adapter
+
+import (
+	"sync"
+	"time"
+)
+
+type memoryBucket struct {
+	tokens       float64
+	lastRefill   time.Time
+	blockedUntil time.Time
+}
+
+// RateLimitMemoryStorageAdapter keeps one token bucket per key in memory,
+// guarded by a single mutex. It is the default adapter used when no other
+// StorageAdapter is configured.
+type RateLimitMemoryStorageAdapter struct {
+	mutex   sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+func NewRateLimitMemoryStorageAdapter() *RateLimitMemoryStorageAdapter {
+	return &RateLimitMemoryStorageAdapter{
+		buckets: map[string]*memoryBucket{},
+	}
+}
+
+func (a *RateLimitMemoryStorageAdapter) Take(key string, maxRequestsPerSecond int64, burstSize int64, blockTimeMilliseconds int64) (*LimitResult, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+	capacity := float64(maxRequestsPerSecond)
+	if burstSize > 0 {
+		capacity = float64(burstSize)
+	}
+	limit := int64(capacity)
+
+	bucket, ok := a.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, lastRefill: now}
+		a.buckets[key] = bucket
+	}
+
+	if now.Before(bucket.blockedUntil) {
+		return &LimitResult{
+			Allowed:   false,
+			Remaining: int64(bucket.tokens),
+			ResetMs:   bucket.blockedUntil.Sub(now).Milliseconds(),
+			Limit:     limit,
+		}, nil
+	}
+
+	elapsedSeconds := now.Sub(bucket.lastRefill).Seconds()
+	bucket.tokens = minFloat64(capacity, bucket.tokens+elapsedSeconds*float64(maxRequestsPerSecond))
+	bucket.lastRefill = now
+
+	if bucket.tokens < 1 {
+		bucket.blockedUntil = now.Add(time.Duration(blockTimeMilliseconds) * time.Millisecond)
+		return &LimitResult{
+			Allowed:   false,
+			Remaining: int64(bucket.tokens),
+			ResetMs:   blockTimeMilliseconds,
+			Limit:     limit,
+		}, nil
+	}
+
+	bucket.tokens--
+
+	return &LimitResult{
+		Allowed:   true,
+		Remaining: int64(bucket.tokens),
+		ResetMs:   refillResetMs(maxRequestsPerSecond, bucket.tokens, capacity),
+		Limit:     limit,
+		Smoothed:  burstSize > 0 && bucket.tokens < capacity-1,
+	}, nil
+}
+
+// refillResetMs estimates how long, in milliseconds, until the bucket next
+// gains a whole token - zero when it is already full.
+func refillResetMs(maxRequestsPerSecond int64, tokens float64, capacity float64) int64 {
+	if tokens >= capacity || maxRequestsPerSecond <= 0 {
+		return 0
+	}
+	return int64(1000 / float64(maxRequestsPerSecond))
+}
+
+func minFloat64(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitMemoryStorageAdapter_Take_AllowsUpToCapacity(t *testing.T) {
+	a := NewRateLimitMemoryStorageAdapter()
+
+	for i := 0; i < 5; i++ {
+		result, err := a.Take("key", 5, 0, 1000)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("request %d: expected allowed, got blocked", i)
+		}
+	}
+
+	result, err := a.Take("key", 5, 0, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Allowed {
+		t.Fatal("expected the 6th request to be blocked")
+	}
+}
+
+func TestRateLimitMemoryStorageAdapter_Take_BlocksForBlockTime(t *testing.T) {
+	a := NewRateLimitMemoryStorageAdapter()
+
+	// A capacity-1 bucket (burstSize 1) refilling at 20/s gets a token back
+	// every 50ms, well inside the 150ms sleep below, so the only thing
+	// gating the 3rd request is whether blockedUntil (10ms) has been
+	// honored.
+	a.Take("key", 20, 1, 10)
+	blocked, err := a.Take("key", 20, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if blocked.Allowed {
+		t.Fatal("expected second request to be blocked")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	allowed, err := a.Take("key", 20, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !allowed.Allowed {
+		t.Fatal("expected request to be allowed once blockTime elapsed and a token refilled")
+	}
+}
+
+func TestRateLimitMemoryStorageAdapter_Take_BurstAllowsMoreThanRate(t *testing.T) {
+	a := NewRateLimitMemoryStorageAdapter()
+
+	for i := 0; i < 3; i++ {
+		result, err := a.Take("key", 1, 3, 1000)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("request %d: expected burst capacity to allow it, got blocked", i)
+		}
+	}
+
+	result, _ := a.Take("key", 1, 3, 1000)
+	if result.Allowed {
+		t.Fatal("expected burst capacity to be exhausted by the 4th request")
+	}
+}
+
+func TestRateLimitMemoryStorageAdapter_Take_SmoothedOnlyWhenDippingIntoBurst(t *testing.T) {
+	a := NewRateLimitMemoryStorageAdapter()
+
+	result, err := a.Take("key", 1, 3, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Smoothed {
+		t.Fatal("expected the first request, still within steady capacity, not to be flagged as smoothed")
+	}
+
+	a.Take("key", 1, 3, 1000)
+	third, _ := a.Take("key", 1, 3, 1000)
+	if !third.Smoothed {
+		t.Fatal("expected a request dipping into burst capacity to be flagged as smoothed")
+	}
+}
+
+func TestRefillResetMs(t *testing.T) {
+	if got := refillResetMs(5, 5, 5); got != 0 {
+		t.Fatalf("expected 0 when the bucket is already full, got %d", got)
+	}
+
+	if got := refillResetMs(0, 0, 5); got != 0 {
+		t.Fatalf("expected 0 when maxRequestsPerSecond is non-positive, got %d", got)
+	}
+
+	if got := refillResetMs(5, 4, 5); got != 200 {
+		t.Fatalf("expected 200ms to refill one token at 5/s, got %d", got)
+	}
+}
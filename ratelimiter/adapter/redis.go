@@ -0,0 +1,304 @@
+package adapter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed take_token.lua
+var takeTokenScript string
+
+type pipelineRequest struct {
+	key                   string
+	maxRequestsPerSecond  int64
+	burstSize             int64
+	blockTimeMilliseconds int64
+	resultCh              chan pipelineResult
+}
+
+type pipelineResult struct {
+	result *LimitResult
+	err    error
+}
+
+// RateLimitRedisStorageAdapterOption configures a RateLimitRedisStorageAdapter.
+type RateLimitRedisStorageAdapterOption func(*RateLimitRedisStorageAdapter)
+
+// WithPipelineWindow batches Take calls into a single Redis pipeline,
+// flushed at least this often. A zero window (the default) disables
+// pipelining and issues one round trip per Take call.
+func WithPipelineWindow(window time.Duration) RateLimitRedisStorageAdapterOption {
+	return func(a *RateLimitRedisStorageAdapter) { a.pipelineWindow = window }
+}
+
+// WithPipelineLimit flushes the pending pipeline as soon as it collects
+// this many requests, even if PipelineWindow has not elapsed yet. It has
+// no effect unless PipelineWindow is set as well.
+func WithPipelineLimit(limit int) RateLimitRedisStorageAdapterOption {
+	return func(a *RateLimitRedisStorageAdapter) { a.pipelineLimit = limit }
+}
+
+// WithPipelineTimeout bounds how long a single flush's Redis round trip may
+// take. A batch that times out reports its commands as failed, which the
+// rate limiter middleware treats as allowed (it fails open on storage
+// errors), so those requests go through rather than wedging forever.
+// Defaults to 2s.
+func WithPipelineTimeout(timeout time.Duration) RateLimitRedisStorageAdapterOption {
+	return func(a *RateLimitRedisStorageAdapter) { a.pipelineTimeout = timeout }
+}
+
+// WithMaxInFlightFlushes bounds how many pipeline flushes may be running at
+// once. New batches keep collecting and flushing concurrently up to this
+// limit instead of queuing behind whichever flush happens to be slow.
+// Defaults to 4.
+func WithMaxInFlightFlushes(n int) RateLimitRedisStorageAdapterOption {
+	return func(a *RateLimitRedisStorageAdapter) { a.maxInFlightFlushes = n }
+}
+
+// RateLimitRedisStorageAdapter stores each bucket as a Redis hash
+// (tokens, last_refill_ms, blocked_until) and takes tokens atomically
+// through a Lua script so concurrent requests for the same key never race.
+// When PipelineWindow is set, Take calls are batched and
+// flushed together through a single redis.Pipeliner instead of each
+// issuing its own round trip; call Close to stop the background flusher
+// once the adapter is no longer needed.
+type RateLimitRedisStorageAdapter struct {
+	client redis.UniversalClient
+
+	pipelineWindow     time.Duration
+	pipelineLimit      int
+	pipelineTimeout    time.Duration
+	maxInFlightFlushes int
+
+	pending   chan *pipelineRequest
+	stopped   chan struct{}
+	flushSem  chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRateLimitRedisStorageAdapter builds a dedicated *redis.Client from the
+// given address/password/db. To share a connection pool (or use a cluster
+// or sentinel client) across limiters, build the client yourself and use
+// NewRateLimitRedisStorageAdapterWithClient instead.
+func NewRateLimitRedisStorageAdapter(address string, password string, db int64, opts ...RateLimitRedisStorageAdapterOption) *RateLimitRedisStorageAdapter {
+	client := redis.NewClient(&redis.Options{
+		Addr:     address,
+		Password: password,
+		DB:       int(db),
+	})
+
+	return NewRateLimitRedisStorageAdapterWithClient(client, opts...)
+}
+
+// NewRateLimitRedisStorageAdapterWithClient builds an adapter around a
+// user-supplied redis.UniversalClient, so operators can share pools and use
+// cluster/sentinel deployments across limiters.
+func NewRateLimitRedisStorageAdapterWithClient(client redis.UniversalClient, opts ...RateLimitRedisStorageAdapterOption) *RateLimitRedisStorageAdapter {
+	a := &RateLimitRedisStorageAdapter{client: client}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.pipelineWindow > 0 {
+		if a.pipelineTimeout <= 0 {
+			a.pipelineTimeout = 2 * time.Second
+		}
+		if a.maxInFlightFlushes <= 0 {
+			a.maxInFlightFlushes = 4
+		}
+
+		a.pending = make(chan *pipelineRequest)
+		a.stopped = make(chan struct{})
+		a.flushSem = make(chan struct{}, a.maxInFlightFlushes)
+		go a.runPipeline()
+	}
+
+	return a
+}
+
+// Close stops the background pipelining goroutine, flushing whatever batch
+// is still pending before returning. It is a no-op when PipelineWindow is
+// unset. The adapter must not be used after Close.
+func (a *RateLimitRedisStorageAdapter) Close() error {
+	if a.pending == nil {
+		return nil
+	}
+
+	a.closeOnce.Do(func() { close(a.pending) })
+	<-a.stopped
+
+	return nil
+}
+
+func (a *RateLimitRedisStorageAdapter) Take(key string, maxRequestsPerSecond int64, burstSize int64, blockTimeMilliseconds int64) (*LimitResult, error) {
+	if a.pending == nil {
+		return a.takeNow(key, maxRequestsPerSecond, burstSize, blockTimeMilliseconds)
+	}
+
+	req := &pipelineRequest{
+		key:                   key,
+		maxRequestsPerSecond:  maxRequestsPerSecond,
+		burstSize:             burstSize,
+		blockTimeMilliseconds: blockTimeMilliseconds,
+		resultCh:              make(chan pipelineResult, 1),
+	}
+
+	a.pending <- req
+	res := <-req.resultCh
+
+	return res.result, res.err
+}
+
+func (a *RateLimitRedisStorageAdapter) takeNow(key string, maxRequestsPerSecond int64, burstSize int64, blockTimeMilliseconds int64) (*LimitResult, error) {
+	capacity := bucketCapacity(maxRequestsPerSecond, burstSize)
+
+	result, err := a.client.Eval(context.Background(), takeTokenScript, []string{key}, capacity, maxRequestsPerSecond, blockTimeMilliseconds, time.Now().UnixMilli()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run take token script: %w", err)
+	}
+
+	return parseLimitResult(result, burstSize, capacity, maxRequestsPerSecond, blockTimeMilliseconds)
+}
+
+// runPipeline collects pending Take requests and flushes them together
+// through a single redis.Pipeliner whenever pipelineWindow elapses or
+// pipelineLimit requests have queued up, whichever comes first. Flushes run
+// on their own goroutine, bounded by maxInFlightFlushes, so a slow Redis
+// round trip stalls at most that many batches instead of every caller in
+// the process - the final flush on shutdown is the one exception, run
+// inline so Close can wait for it to finish.
+func (a *RateLimitRedisStorageAdapter) runPipeline() {
+	defer close(a.stopped)
+
+	window := a.pipelineWindow
+	if window <= 0 {
+		window = time.Millisecond
+	}
+
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	var batch []*pipelineRequest
+
+	flush := func(async bool) {
+		if len(batch) == 0 {
+			return
+		}
+		pending := batch
+		batch = nil
+
+		if !async {
+			a.flushBatch(pending)
+			return
+		}
+
+		a.flushSem <- struct{}{}
+		go func() {
+			defer func() { <-a.flushSem }()
+			a.flushBatch(pending)
+		}()
+	}
+
+	for {
+		select {
+		case req, ok := <-a.pending:
+			if !ok {
+				flush(false)
+				return
+			}
+
+			batch = append(batch, req)
+			if a.pipelineLimit > 0 && len(batch) >= a.pipelineLimit {
+				flush(true)
+			}
+		case <-ticker.C:
+			flush(true)
+		}
+	}
+}
+
+// flushBatch runs one batch through a single Redis pipeline, bounded by
+// pipelineTimeout. Each request's own command result is checked
+// independently: go-redis reports pipe.Exec's error as the first error
+// among its commands, but the rest can still have succeeded, so a single
+// bad command must not fail the whole batch alongside it.
+func (a *RateLimitRedisStorageAdapter) flushBatch(batch []*pipelineRequest) {
+	ctx := context.Background()
+	if a.pipelineTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.pipelineTimeout)
+		defer cancel()
+	}
+
+	pipe := a.client.Pipeline()
+	cmds := make([]*redis.Cmd, len(batch))
+
+	for i, req := range batch {
+		capacity := bucketCapacity(req.maxRequestsPerSecond, req.burstSize)
+		cmds[i] = pipe.Eval(ctx, takeTokenScript, []string{req.key}, capacity, req.maxRequestsPerSecond, req.blockTimeMilliseconds, time.Now().UnixMilli())
+	}
+
+	pipe.Exec(ctx)
+
+	for i, req := range batch {
+		result, err := cmds[i].Result()
+		if err != nil {
+			req.resultCh <- pipelineResult{err: fmt.Errorf("failed to run take token script: %w", err)}
+			continue
+		}
+
+		parsed, err := parseLimitResult(result, req.burstSize, bucketCapacity(req.maxRequestsPerSecond, req.burstSize), req.maxRequestsPerSecond, req.blockTimeMilliseconds)
+		req.resultCh <- pipelineResult{result: parsed, err: err}
+	}
+}
+
+func bucketCapacity(maxRequestsPerSecond int64, burstSize int64) int64 {
+	if burstSize > 0 {
+		return burstSize
+	}
+	return maxRequestsPerSecond
+}
+
+func parseLimitResult(result interface{}, burstSize int64, capacity int64, maxRequestsPerSecond int64, blockTimeMilliseconds int64) (*LimitResult, error) {
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("unexpected response from take token script: %v", result)
+	}
+
+	allowed, _ := values[0].(int64)
+
+	remaining, err := strconv.ParseFloat(fmt.Sprintf("%v", values[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected remaining tokens in take token script response: %w", err)
+	}
+
+	blockedUntil, err := strconv.ParseInt(fmt.Sprintf("%v", values[2]), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected blocked_until in take token script response: %w", err)
+	}
+
+	if allowed != 1 {
+		resetMs := blockTimeMilliseconds
+		if blockedUntil > 0 {
+			if remainingMs := blockedUntil - time.Now().UnixMilli(); remainingMs > 0 {
+				resetMs = remainingMs
+			}
+		}
+		return &LimitResult{Allowed: false, Remaining: int64(remaining), ResetMs: resetMs, Limit: capacity}, nil
+	}
+
+	return &LimitResult{
+		Allowed:   true,
+		Remaining: int64(remaining),
+		ResetMs:   refillResetMs(maxRequestsPerSecond, remaining, float64(capacity)),
+		Limit:     capacity,
+		Smoothed:  burstSize > 0 && remaining < float64(capacity)-1,
+	}, nil
+}
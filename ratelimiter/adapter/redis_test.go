@@ -0,0 +1,177 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newMiniredisAdapter(t *testing.T, opts ...RateLimitRedisStorageAdapterOption) (*RateLimitRedisStorageAdapter, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %s", err)
+	}
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	a := NewRateLimitRedisStorageAdapterWithClient(client, opts...)
+	t.Cleanup(func() { a.Close() })
+
+	return a, mr
+}
+
+func TestRateLimitRedisStorageAdapter_Take_FlushesOnPipelineLimitBeforeWindow(t *testing.T) {
+	a, _ := newMiniredisAdapter(t, WithPipelineWindow(time.Hour), WithPipelineLimit(2))
+
+	done := make(chan *LimitResult, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			result, err := a.Take("limit-key", 5, 0, 1000)
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+				return
+			}
+			done <- result
+		}()
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-done:
+			if !result.Allowed {
+				t.Error("expected request to be allowed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the batch to flush once PipelineLimit was reached, well before the hour-long PipelineWindow")
+		}
+	}
+}
+
+func TestRateLimitRedisStorageAdapter_Take_FlushesOnPipelineWindow(t *testing.T) {
+	a, _ := newMiniredisAdapter(t, WithPipelineWindow(20*time.Millisecond))
+
+	result, err := a.Take("window-key", 5, 0, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.Allowed {
+		t.Fatal("expected request to be allowed")
+	}
+}
+
+func TestRateLimitRedisStorageAdapter_FlushBatch_OneBadCommandDoesNotFailSiblings(t *testing.T) {
+	a, mr := newMiniredisAdapter(t, WithPipelineWindow(time.Hour), WithPipelineLimit(2))
+
+	// "bad-key" already holds a plain string, so the script's HGET against it
+	// fails with WRONGTYPE; "good-key" is untouched and should take its
+	// token normally in the same batch.
+	if err := mr.Set("bad-key", "not-a-hash"); err != nil {
+		t.Fatalf("failed to seed bad-key: %s", err)
+	}
+
+	badCh := make(chan pipelineResult, 1)
+	goodCh := make(chan pipelineResult, 1)
+
+	go func() {
+		result, err := a.Take("bad-key", 5, 0, 1000)
+		badCh <- pipelineResult{result: result, err: err}
+	}()
+	go func() {
+		result, err := a.Take("good-key", 5, 0, 1000)
+		goodCh <- pipelineResult{result: result, err: err}
+	}()
+
+	select {
+	case res := <-badCh:
+		if res.err == nil {
+			t.Fatal("expected bad-key's command to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for bad-key's result")
+	}
+
+	select {
+	case res := <-goodCh:
+		if res.err != nil {
+			t.Fatalf("expected good-key's command to succeed despite bad-key failing in the same batch, got: %s", res.err)
+		}
+		if !res.result.Allowed {
+			t.Fatal("expected good-key's request to be allowed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for good-key's result")
+	}
+}
+
+func TestRateLimitRedisStorageAdapter_Take_ResetMsCountsDownDuringBlock(t *testing.T) {
+	a, _ := newMiniredisAdapter(t)
+
+	result, err := a.Take("reset-ms-key", 1, 1, 2000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.Allowed {
+		t.Fatal("expected the first request to be allowed")
+	}
+
+	result, err = a.Take("reset-ms-key", 1, 1, 2000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Allowed {
+		t.Fatal("expected the second request to be blocked")
+	}
+	if result.ResetMs <= 1900 || result.ResetMs > 2000 {
+		t.Fatalf("expected the first blocked request to report close to the full block time, got %d", result.ResetMs)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	result, err = a.Take("reset-ms-key", 1, 1, 2000)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Allowed {
+		t.Fatal("expected the third request to still be blocked")
+	}
+	if result.ResetMs <= 0 || result.ResetMs > 1600 {
+		t.Fatalf("expected ResetMs to count down from the time already spent blocked, got %d", result.ResetMs)
+	}
+}
+
+func TestRateLimitRedisStorageAdapter_Close_DrainsPendingBatch(t *testing.T) {
+	a, _ := newMiniredisAdapter(t, WithPipelineWindow(time.Hour))
+
+	// Hand the request to runPipeline directly (the test is in-package, so
+	// a.pending is reachable) rather than through a.Take in its own
+	// goroutine: the unbuffered send only returns once runPipeline has
+	// received it, which happens-before the Close call below and avoids
+	// racing that send against Close's close(a.pending).
+	req := &pipelineRequest{
+		key:                   "close-key",
+		maxRequestsPerSecond:  5,
+		blockTimeMilliseconds: 1000,
+		resultCh:              make(chan pipelineResult, 1),
+	}
+	a.pending <- req
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("unexpected error from Close: %s", err)
+	}
+
+	select {
+	case res := <-req.resultCh:
+		if res.err != nil {
+			t.Fatalf("expected Close to drain the pending batch, got error: %s", res.err)
+		}
+		if !res.result.Allowed {
+			t.Fatal("expected the drained request to be allowed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Close to drain the pending batch")
+	}
+}
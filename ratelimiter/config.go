@@ -3,27 +3,43 @@ package ratelimiter
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/adapter"
+	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/configsource"
 	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/responsewriter"
 )
 
 const envKeyIPMaxRequestsPerSecond = "RATE_LIMITER_IP_MAX_REQUESTS"
 const envKeyIPBlockTimeMilliseconds = "RATE_LIMITER_IP_BLOCK_TIME"
+const envKeyIPBurstSize = "RATE_LIMITER_IP_BURST"
 const envKeyTokenMaxRequestsPerSecond = "RATE_LIMITER_TOKEN_MAX_REQUESTS"
 const envKeyTokenBlockTimeMilliseconds = "RATE_LIMITER_TOKEN_BLOCK_TIME"
+const envKeyTokenBurstSize = "RATE_LIMITER_TOKEN_BURST"
 const envKeyDebug = "RATE_LIMITER_DEBUG"
+const envKeyEmitHeadersOnSuccess = "RATE_LIMITER_EMIT_HEADERS_ON_SUCCESS"
 const envUseRedis = "RATE_LIMITER_USE_REDIS"
 const envRedisAddress = "RATE_LIMITER_REDIS_ADDRESS"
 const envRedisPassword = "RATE_LIMITER_REDIS_PASSWORD"
 const envRedisDB = "RATE_LIMITER_REDIS_DB"
+const envRedisPipelineWindow = "RATE_LIMITER_REDIS_PIPELINE_WINDOW"
+const envRedisPipelineLimit = "RATE_LIMITER_REDIS_PIPELINE_LIMIT"
+const envExemptCIDRs = "RATE_LIMITER_EXEMPT_CIDRS"
+const envExemptUserAgents = "RATE_LIMITER_EXEMPT_USER_AGENTS"
 
 type RateLimiterRateConfig struct {
 	MaxRequestsPerSecond  int64 `json:"maxRequestsPerSecond"`
 	BlockTimeMilliseconds int64 `json:"blockTimeMilliseconds"`
+	// BurstSize, when greater than zero, turns the bucket into a proper
+	// token bucket: up to BurstSize requests are allowed in a short burst
+	// while tokens keep refilling at MaxRequestsPerSecond. A zero value
+	// preserves the previous fixed-window behavior.
+	BurstSize int64 `json:"burstSize"`
 }
 
 type RateLimiterConfig struct {
@@ -34,9 +50,36 @@ type RateLimiterConfig struct {
 	ResponseWriter responsewriter.RateLimiterResponseWriter `json:"-"`
 	Debug          bool                                     `json:"debug"`
 	DisableEnvs    bool                                     `json:"disableEnvs"`
+	// OnRateLimitSmoothing, when set, is called whenever a request is
+	// allowed only because it dipped into burst capacity rather than the
+	// steady refill rate.
+	OnRateLimitSmoothing func(RateLimitSmoothingEvent) `json:"-"`
+	// ConfigSource, when set, is subscribed to on startup so that IP,
+	// Token and CustomTokens can be hot-swapped without restarting the
+	// service. See applyConfigSourceSnapshot.
+	ConfigSource configsource.ConfigSource `json:"-"`
+	// Exemptions lists rules that, when matched, make a request skip rate
+	// limit accounting entirely.
+	Exemptions []ExemptionRule `json:"-"`
+	// Identity resolves an authenticated identity from the request when no
+	// API_KEY header is present, so it can be rate limited on its own
+	// bucket instead of falling back to the IP limiter.
+	Identity IdentityExtractor `json:"-"`
+	// Tiers maps a tier name (as returned by Identity.Extract) to the rate
+	// config applied to it. Identities whose tier has no entry here fall
+	// back to Token.
+	Tiers map[string]*RateLimiterRateConfig `json:"tiers"`
+	// EmitHeadersOnSuccess makes the ResponseWriter emit the RateLimit-*
+	// headers on allowed requests too, not just on blocked ones.
+	EmitHeadersOnSuccess bool `json:"emitHeadersOnSuccess"`
+
+	mu sync.RWMutex
 }
 
 func (c *RateLimiterConfig) GetRateLimiterRateConfigForToken(token string) (*RateLimiterRateConfig, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	customTokenConfig, ok := (*c.CustomTokens)[token]
 	if ok {
 		return customTokenConfig, true
@@ -45,6 +88,64 @@ func (c *RateLimiterConfig) GetRateLimiterRateConfigForToken(token string) (*Rat
 	}
 }
 
+func (c *RateLimiterConfig) getIP() *RateLimiterRateConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.IP
+}
+
+func (c *RateLimiterConfig) getToken() *RateLimiterRateConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Token
+}
+
+// applyConfigSourceSnapshot hot-swaps IP, Token and CustomTokens from a
+// configsource.Snapshot under c.mu, so readers in flight always see either
+// the old or the new config and never a partial mix of the two. IP and
+// Token are only swapped in when set on the snapshot; CustomTokens always
+// fully replaces the previous map, even with an empty one, per the field
+// semantics documented on configsource.Snapshot. A snapshot that fails
+// configsource.ValidateSnapshot (e.g. a zero MaxRequestsPerSecond) is
+// dropped instead of applied - GRPCConfigSource can NACK one of those back
+// to its control plane, but not every ConfigSource has anyone to roll back
+// to, so the guard lives here too and covers all of them.
+func (c *RateLimiterConfig) applyConfigSourceSnapshot(snapshot *configsource.Snapshot) {
+	if err := configsource.ValidateSnapshot(snapshot); err != nil {
+		DebugPrintfWithoutKey(c, "dropping invalid config source snapshot version %s: %s", snapshot.Version, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if snapshot.IP != nil {
+		c.IP = toRateLimiterRateConfig(snapshot.IP)
+	}
+
+	if snapshot.Token != nil {
+		c.Token = toRateLimiterRateConfig(snapshot.Token)
+	}
+
+	customTokens := map[string]*RateLimiterRateConfig{}
+	for token, rateConfig := range snapshot.CustomTokens {
+		customTokens[token] = toRateLimiterRateConfig(rateConfig)
+	}
+	c.CustomTokens = &customTokens
+
+	DebugPrintfWithoutKey(c, "applied config source snapshot version %s", snapshot.Version)
+}
+
+func toRateLimiterRateConfig(rateConfig *configsource.RateConfig) *RateLimiterRateConfig {
+	return &RateLimiterRateConfig{
+		MaxRequestsPerSecond:  rateConfig.MaxRequestsPerSecond,
+		BlockTimeMilliseconds: rateConfig.BlockTimeMilliseconds,
+		BurstSize:             rateConfig.BurstSize,
+	}
+}
+
 func getDefaultConfiguration() *RateLimiterConfig {
 	return &RateLimiterConfig{
 		IP: &RateLimiterRateConfig{
@@ -75,6 +176,12 @@ func setConfiguration(config *RateLimiterConfig) *RateLimiterConfig {
 			config.Debug = debug
 			DebugPrintfWithoutKey(config, "using env %s", envKeyDebug)
 		}
+
+		emitHeadersOnSuccess, ok := getBoolEnv(envKeyEmitHeadersOnSuccess)
+		if ok {
+			config.EmitHeadersOnSuccess = emitHeadersOnSuccess
+			DebugPrintfWithoutKey(config, "using env %s", envKeyEmitHeadersOnSuccess)
+		}
 	}
 
 	configureIP(config, defaultConfiguration)
@@ -82,6 +189,8 @@ func setConfiguration(config *RateLimiterConfig) *RateLimiterConfig {
 	configureCustomTokens(config, defaultConfiguration)
 	configureStorageAdapter(config, defaultConfiguration)
 	configureResponseWriter(config, defaultConfiguration)
+	configureExemptions(config)
+	configureTiers(config)
 
 	if config.Debug {
 		jsonConfiguration, err := json.Marshal(config)
@@ -110,6 +219,12 @@ func configureIP(config *RateLimiterConfig, defaultConfiguration *RateLimiterCon
 			config.IP.BlockTimeMilliseconds = bt
 			DebugPrintfWithoutKey(config, "using env %s", envKeyIPBlockTimeMilliseconds)
 		}
+
+		burst, ok := getInt64Env(envKeyIPBurstSize)
+		if ok {
+			config.IP.BurstSize = burst
+			DebugPrintfWithoutKey(config, "using env %s", envKeyIPBurstSize)
+		}
 	}
 }
 
@@ -130,6 +245,12 @@ func configureToken(config *RateLimiterConfig, defaultConfiguration *RateLimiter
 			config.Token.BlockTimeMilliseconds = bt
 			DebugPrintfWithoutKey(config, "using env %s", envKeyTokenBlockTimeMilliseconds)
 		}
+
+		burst, ok := getInt64Env(envKeyTokenBurstSize)
+		if ok {
+			config.Token.BurstSize = burst
+			DebugPrintfWithoutKey(config, "using env %s", envKeyTokenBurstSize)
+		}
 	}
 }
 
@@ -152,7 +273,7 @@ func configureCustomTokens(config *RateLimiterConfig, defaultConfiguration *Rate
 }
 
 func getCustomTokenList() *[]string {
-	envKeyRegex := regexp.MustCompile("^RATE_LIMITER_TOKEN_(.*)_(MAX_REQUESTS|BLOCK_TIME)$")
+	envKeyRegex := regexp.MustCompile("^RATE_LIMITER_TOKEN_(.*)_(MAX_REQUESTS|BLOCK_TIME|BURST)$")
 
 	foundTokens := map[string]bool{}
 
@@ -193,9 +314,92 @@ func configureCustomToken(config *RateLimiterConfig, defaultConfiguration *RateL
 		blockTimeMilliseconds = defaultValue
 	}
 
+	burstSizeEnvKey := fmt.Sprintf("RATE_LIMITER_TOKEN_%s_BURST", customToken)
+	burstSize, ok := getInt64Env(burstSizeEnvKey)
+	if !ok {
+		defaultValue := config.Token.BurstSize
+		DebugPrintfWithoutKey(config, "env \"%s\" not found: using default value %d", burstSizeEnvKey, defaultValue)
+		burstSize = defaultValue
+	}
+
 	(*config.CustomTokens)[customToken] = &RateLimiterRateConfig{
 		MaxRequestsPerSecond:  maxRequestsPerSecond,
 		BlockTimeMilliseconds: blockTimeMilliseconds,
+		BurstSize:             burstSize,
+	}
+}
+
+func configureTiers(config *RateLimiterConfig) {
+	if config.Tiers == nil {
+		config.Tiers = map[string]*RateLimiterRateConfig{}
+	}
+
+	for tier, rateConfig := range config.Tiers {
+		if rateConfig == nil {
+			config.Tiers[tier] = config.Token
+		}
+	}
+
+	if config.DisableEnvs {
+		return
+	}
+
+	for _, tier := range getTierList() {
+		configureTier(config, tier)
+	}
+}
+
+func getTierList() []string {
+	envKeyRegex := regexp.MustCompile("^RATE_LIMITER_TIER_(.*)_(MAX_REQUESTS|BLOCK_TIME|BURST)$")
+
+	foundTiers := map[string]bool{}
+
+	for _, env := range os.Environ() {
+		envKey := strings.SplitN(env, "=", 2)[0]
+		if envKeyRegex.Match([]byte(envKey)) {
+			foundTiers[envKeyRegex.FindStringSubmatch(envKey)[1]] = true
+		}
+	}
+
+	tiers := []string{}
+	for tier := range foundTiers {
+		tiers = append(tiers, tier)
+	}
+
+	return tiers
+}
+
+func configureTier(config *RateLimiterConfig, tier string) {
+	DebugPrintfWithoutKey(config, "configuring tier \"%s\"", tier)
+
+	maxRequestsPerSecondEnvKey := fmt.Sprintf("RATE_LIMITER_TIER_%s_MAX_REQUESTS", tier)
+	maxRequestsPerSecond, ok := getInt64Env(maxRequestsPerSecondEnvKey)
+	if !ok {
+		defaultValue := config.Token.MaxRequestsPerSecond
+		DebugPrintfWithoutKey(config, "env \"%s\" not found: using default value %d", maxRequestsPerSecondEnvKey, defaultValue)
+		maxRequestsPerSecond = defaultValue
+	}
+
+	blockTimeMillisecondEnvKey := fmt.Sprintf("RATE_LIMITER_TIER_%s_BLOCK_TIME", tier)
+	blockTimeMilliseconds, ok := getInt64Env(blockTimeMillisecondEnvKey)
+	if !ok {
+		defaultValue := config.Token.BlockTimeMilliseconds
+		DebugPrintfWithoutKey(config, "env \"%s\" not found: using default value %d", blockTimeMillisecondEnvKey, defaultValue)
+		blockTimeMilliseconds = defaultValue
+	}
+
+	burstSizeEnvKey := fmt.Sprintf("RATE_LIMITER_TIER_%s_BURST", tier)
+	burstSize, ok := getInt64Env(burstSizeEnvKey)
+	if !ok {
+		defaultValue := config.Token.BurstSize
+		DebugPrintfWithoutKey(config, "env \"%s\" not found: using default value %d", burstSizeEnvKey, defaultValue)
+		burstSize = defaultValue
+	}
+
+	config.Tiers[tier] = &RateLimiterRateConfig{
+		MaxRequestsPerSecond:  maxRequestsPerSecond,
+		BlockTimeMilliseconds: blockTimeMilliseconds,
+		BurstSize:             burstSize,
 	}
 }
 
@@ -232,7 +436,21 @@ func configureRedisStorageAdapter(config *RateLimiterConfig) {
 		redisDB = 0
 	}
 
-	config.StorageAdapter = adapter.NewRateLimitRedisStorageAdapter(redisAddress, redisPassword, redisDB)
+	var opts []adapter.RateLimitRedisStorageAdapterOption
+
+	pipelineWindowMs, ok := getInt64Env(envRedisPipelineWindow)
+	if ok {
+		opts = append(opts, adapter.WithPipelineWindow(time.Duration(pipelineWindowMs)*time.Millisecond))
+		DebugPrintfWithoutKey(config, "using env %s", envRedisPipelineWindow)
+	}
+
+	pipelineLimit, ok := getInt64Env(envRedisPipelineLimit)
+	if ok {
+		opts = append(opts, adapter.WithPipelineLimit(int(pipelineLimit)))
+		DebugPrintfWithoutKey(config, "using env %s", envRedisPipelineLimit)
+	}
+
+	config.StorageAdapter = adapter.NewRateLimitRedisStorageAdapter(redisAddress, redisPassword, redisDB, opts...)
 }
 
 func configureResponseWriter(config *RateLimiterConfig, defaultConfiguration *RateLimiterConfig) {
@@ -246,3 +464,30 @@ func configureResponseWriter(config *RateLimiterConfig, defaultConfiguration *Ra
 		DebugPrintfWithoutKey(config, "using ResponseWriter Default")
 	}
 }
+
+func configureExemptions(config *RateLimiterConfig) {
+	if config.DisableEnvs {
+		return
+	}
+
+	cidrs, ok := getStringEnv(envExemptCIDRs)
+	if ok {
+		for _, cidr := range strings.Split(cidrs, ",") {
+			_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+			if err != nil {
+				DebugPrintfWithoutKey(config, "ignoring invalid CIDR \"%s\" from env %s: %s", cidr, envExemptCIDRs, err)
+				continue
+			}
+			config.Exemptions = append(config.Exemptions, ExemptionRule{CIDR: ipNet})
+		}
+		DebugPrintfWithoutKey(config, "using env %s", envExemptCIDRs)
+	}
+
+	userAgents, ok := getStringEnv(envExemptUserAgents)
+	if ok {
+		for _, userAgent := range strings.Split(userAgents, ",") {
+			config.Exemptions = append(config.Exemptions, ExemptionRule{UserAgent: strings.TrimSpace(userAgent)})
+		}
+		DebugPrintfWithoutKey(config, "using env %s", envExemptUserAgents)
+	}
+}
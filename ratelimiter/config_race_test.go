@@ -0,0 +1,56 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/configsource"
+)
+
+type staticIdentityExtractor struct{}
+
+func (staticIdentityExtractor) Extract(r *http.Request) (string, string, bool) {
+	return "user-1", "", true
+}
+
+// TestRateLimiterConfig_TakeIdentity_ConcurrentWithConfigSourceSnapshot
+// replicates a ConfigSource pushing snapshots while requests are in flight:
+// run with -race, it catches a Token read in takeIdentity that is not
+// guarded by the same RWMutex applyConfigSourceSnapshot writes under.
+func TestRateLimiterConfig_TakeIdentity_ConcurrentWithConfigSourceSnapshot(t *testing.T) {
+	config := setConfiguration(&RateLimiterConfig{
+		DisableEnvs: true,
+		Identity:    staticIdentityExtractor{},
+	})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			config.applyConfigSourceSnapshot(&configsource.Snapshot{
+				Token: &configsource.RateConfig{MaxRequestsPerSecond: int64(i + 1), BlockTimeMilliseconds: 1000},
+			})
+		}
+		close(stop)
+	}()
+
+	go func() {
+		defer wg.Done()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				config.takeIdentity(r)
+			}
+		}
+	}()
+
+	wg.Wait()
+}
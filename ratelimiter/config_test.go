@@ -0,0 +1,40 @@
+package ratelimiter
+
+import (
+	"testing"
+
+	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/configsource"
+)
+
+// TestRateLimiterConfig_ApplyConfigSourceSnapshot_RejectsInvalid covers the
+// hole fixed in GRPCConfigSource by commit fc2d2ce but missed for every
+// other ConfigSource: applyConfigSourceSnapshot itself must drop a snapshot
+// that fails configsource.ValidateSnapshot instead of hot-swapping it in,
+// since a source like FileConfigSource has no control plane to NACK back
+// to.
+func TestRateLimiterConfig_ApplyConfigSourceSnapshot_RejectsInvalid(t *testing.T) {
+	config := setConfiguration(&RateLimiterConfig{DisableEnvs: true})
+	originalToken := config.getToken()
+
+	config.applyConfigSourceSnapshot(&configsource.Snapshot{
+		Version: "bad",
+		Token:   &configsource.RateConfig{MaxRequestsPerSecond: 0},
+	})
+
+	if got := config.getToken(); got != originalToken {
+		t.Fatalf("expected invalid snapshot to be dropped, Token changed to %+v", got)
+	}
+}
+
+func TestRateLimiterConfig_ApplyConfigSourceSnapshot_AppliesValid(t *testing.T) {
+	config := setConfiguration(&RateLimiterConfig{DisableEnvs: true})
+
+	config.applyConfigSourceSnapshot(&configsource.Snapshot{
+		Version: "good",
+		Token:   &configsource.RateConfig{MaxRequestsPerSecond: 42, BlockTimeMilliseconds: 1000},
+	})
+
+	if got := config.getToken(); got == nil || got.MaxRequestsPerSecond != 42 {
+		t.Fatalf("expected valid snapshot to be applied, got %+v", got)
+	}
+}
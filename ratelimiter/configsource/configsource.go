@@ -0,0 +1,79 @@
+package configsource
+
+import (
+	"context"
+	"fmt"
+)
+
+// RateConfig mirrors ratelimiter.RateLimiterRateConfig so this package does
+// not need to import the ratelimiter package back (which imports this one).
+type RateConfig struct {
+	MaxRequestsPerSecond  int64 `json:"maxRequestsPerSecond" yaml:"maxRequestsPerSecond"`
+	BlockTimeMilliseconds int64 `json:"blockTimeMilliseconds" yaml:"blockTimeMilliseconds"`
+	BurstSize             int64 `json:"burstSize" yaml:"burstSize"`
+}
+
+// Snapshot is a versioned set of rate limit configs published by a
+// ConfigSource. IP and Token are swapped in only when present, so a source
+// that changes just one of them does not need to resend the other -
+// leaving a field nil keeps whatever was running before. CustomTokens is
+// different: it always fully replaces the previous map, including clearing
+// it out when CustomTokens is nil, so a source retires a custom token
+// simply by no longer including it.
+type Snapshot struct {
+	Version      string                 `json:"version" yaml:"version"`
+	IP           *RateConfig            `json:"ip" yaml:"ip"`
+	Token        *RateConfig            `json:"token" yaml:"token"`
+	CustomTokens map[string]*RateConfig `json:"tokens" yaml:"tokens"`
+}
+
+// ConfigSource lets rate limit configuration be changed centrally, without
+// restarting the service. Subscribe returns a channel that receives a new
+// Snapshot every time the underlying configuration changes; it is closed
+// when ctx is done or the source can no longer produce updates.
+type ConfigSource interface {
+	Subscribe(ctx context.Context) (<-chan *Snapshot, error)
+}
+
+// ValidateSnapshot rejects a Snapshot whose rate configs could not
+// possibly run: a non-positive MaxRequestsPerSecond, or a negative
+// BlockTimeMilliseconds/BurstSize. It does not know about anything beyond
+// the Snapshot itself (e.g. it cannot tell a stale version from a current
+// one) - that is left to whoever applies the snapshot.
+func ValidateSnapshot(snapshot *Snapshot) error {
+	if err := validateRateConfig("ip", snapshot.IP); err != nil {
+		return err
+	}
+
+	if err := validateRateConfig("token", snapshot.Token); err != nil {
+		return err
+	}
+
+	for name, rateConfig := range snapshot.CustomTokens {
+		if err := validateRateConfig(fmt.Sprintf("tokens.%s", name), rateConfig); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateRateConfig(field string, rateConfig *RateConfig) error {
+	if rateConfig == nil {
+		return nil
+	}
+
+	if rateConfig.MaxRequestsPerSecond <= 0 {
+		return fmt.Errorf("%s.maxRequestsPerSecond must be positive, got %d", field, rateConfig.MaxRequestsPerSecond)
+	}
+
+	if rateConfig.BlockTimeMilliseconds < 0 {
+		return fmt.Errorf("%s.blockTimeMilliseconds must not be negative, got %d", field, rateConfig.BlockTimeMilliseconds)
+	}
+
+	if rateConfig.BurstSize < 0 {
+		return fmt.Errorf("%s.burstSize must not be negative, got %d", field, rateConfig.BurstSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package configsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfigSource reloads a YAML or JSON file (picked by its extension)
+// every time it changes on disk.
+type FileConfigSource struct {
+	path string
+}
+
+func NewFileConfigSource(path string) *FileConfigSource {
+	return &FileConfigSource{path: path}
+}
+
+func (s *FileConfigSource) Subscribe(ctx context.Context) (<-chan *Snapshot, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", s.path, err)
+	}
+
+	ch := make(chan *Snapshot)
+
+	emit := func() {
+		snapshot, err := s.load()
+		if err != nil {
+			return
+		}
+		select {
+		case ch <- snapshot:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		defer watcher.Close()
+
+		emit()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(s.path) && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					emit()
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (s *FileConfigSource) load() (*Snapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+
+	snapshot := &Snapshot{}
+
+	if strings.HasSuffix(s.path, ".json") {
+		if err := json.Unmarshal(data, snapshot); err != nil {
+			return nil, fmt.Errorf("failed to parse %s as json: %w", s.path, err)
+		}
+	} else {
+		if err := yaml.Unmarshal(data, snapshot); err != nil {
+			return nil, fmt.Errorf("failed to parse %s as yaml: %w", s.path, err)
+		}
+	}
+
+	return snapshot, nil
+}
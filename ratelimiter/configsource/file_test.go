@@ -0,0 +1,87 @@
+package configsource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileConfigSource_Subscribe_EmitsInitialAndReloadedSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	writeFile(t, path, `
+version: "v1"
+token:
+  maxRequestsPerSecond: 10
+  blockTimeMilliseconds: 1000
+`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := NewFileConfigSource(path)
+	snapshots, err := source.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	initial := recvSnapshot(t, snapshots)
+	if initial.Version != "v1" || initial.Token.MaxRequestsPerSecond != 10 {
+		t.Fatalf("unexpected initial snapshot: %+v", initial)
+	}
+
+	writeFile(t, path, `
+version: "v2"
+token:
+  maxRequestsPerSecond: 20
+  blockTimeMilliseconds: 2000
+`)
+
+	reloaded := recvSnapshot(t, snapshots)
+	if reloaded.Version != "v2" || reloaded.Token.MaxRequestsPerSecond != 20 {
+		t.Fatalf("unexpected reloaded snapshot: %+v", reloaded)
+	}
+}
+
+func TestFileConfigSource_Subscribe_SupportsJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	writeFile(t, path, `{"version": "v1", "ip": {"maxRequestsPerSecond": 5, "blockTimeMilliseconds": 500}}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source := NewFileConfigSource(path)
+	snapshots, err := source.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	snapshot := recvSnapshot(t, snapshots)
+	if snapshot.IP == nil || snapshot.IP.MaxRequestsPerSecond != 5 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}
+
+func recvSnapshot(t *testing.T, snapshots <-chan *Snapshot) *Snapshot {
+	t.Helper()
+
+	select {
+	case snapshot := <-snapshots:
+		return snapshot
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a snapshot")
+		return nil
+	}
+}
@@ -0,0 +1,93 @@
+package configsource
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeDiscoveryStream replays a fixed list of responses and records every
+// request sent back, so runDiscoveryLoop can be driven without a real gRPC
+// connection.
+type fakeDiscoveryStream struct {
+	responses []*DiscoveryResponse
+	requests  []*DiscoveryRequest
+}
+
+func (s *fakeDiscoveryStream) RecvMsg(m interface{}) error {
+	if len(s.responses) == 0 {
+		return errors.New("no more responses")
+	}
+
+	resp := m.(*DiscoveryResponse)
+	*resp = *s.responses[0]
+	s.responses = s.responses[1:]
+
+	return nil
+}
+
+func (s *fakeDiscoveryStream) SendMsg(m interface{}) error {
+	s.requests = append(s.requests, m.(*DiscoveryRequest))
+	return nil
+}
+
+func TestRunDiscoveryLoop_AcksValidSnapshots(t *testing.T) {
+	stream := &fakeDiscoveryStream{
+		responses: []*DiscoveryResponse{
+			{VersionInfo: "v1", Nonce: "n1", Snapshot: &Snapshot{Version: "v1", Token: &RateConfig{MaxRequestsPerSecond: 10}}},
+		},
+	}
+
+	ch := make(chan *Snapshot, 1)
+	runDiscoveryLoop(context.Background(), stream, ch)
+	close(ch)
+
+	snapshot := <-ch
+	if snapshot == nil || snapshot.Version != "v1" {
+		t.Fatalf("expected to receive the v1 snapshot, got %+v", snapshot)
+	}
+
+	if len(stream.requests) != 1 {
+		t.Fatalf("expected exactly one request sent, got %d", len(stream.requests))
+	}
+	if stream.requests[0].ErrorDetail != "" {
+		t.Fatalf("expected an ACK (no ErrorDetail), got %q", stream.requests[0].ErrorDetail)
+	}
+	if stream.requests[0].VersionInfo != "v1" {
+		t.Fatalf("expected the ACK to carry the new version, got %q", stream.requests[0].VersionInfo)
+	}
+}
+
+func TestRunDiscoveryLoop_NacksInvalidSnapshotAndKeepsPreviousVersion(t *testing.T) {
+	stream := &fakeDiscoveryStream{
+		responses: []*DiscoveryResponse{
+			{VersionInfo: "v1", Nonce: "n1", Snapshot: &Snapshot{Version: "v1", Token: &RateConfig{MaxRequestsPerSecond: 10}}},
+			{VersionInfo: "v2", Nonce: "n2", Snapshot: &Snapshot{Version: "v2", Token: &RateConfig{MaxRequestsPerSecond: 0}}},
+		},
+	}
+
+	ch := make(chan *Snapshot, 2)
+	runDiscoveryLoop(context.Background(), stream, ch)
+	close(ch)
+
+	received := []*Snapshot{}
+	for snapshot := range ch {
+		received = append(received, snapshot)
+	}
+
+	if len(received) != 1 || received[0].Version != "v1" {
+		t.Fatalf("expected only the valid v1 snapshot to be forwarded, got %+v", received)
+	}
+
+	if len(stream.requests) != 2 {
+		t.Fatalf("expected one ACK and one NACK to be sent, got %d", len(stream.requests))
+	}
+
+	nack := stream.requests[1]
+	if nack.ErrorDetail == "" {
+		t.Fatal("expected the invalid snapshot to be NACKed with ErrorDetail set")
+	}
+	if nack.VersionInfo != "v1" {
+		t.Fatalf("expected the NACK to echo the last applied version, got %q", nack.VersionInfo)
+	}
+}
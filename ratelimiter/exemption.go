@@ -0,0 +1,80 @@
+package ratelimiter
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ExemptionRule matches requests that should skip rate-limit accounting
+// entirely, e.g. health-check pollers or internal service mesh traffic.
+// Exactly one of Match, CIDR, UserAgent or Origin should be set; the first
+// one found wins, in that order.
+type ExemptionRule struct {
+	CIDR *net.IPNet
+	// UserAgent is matched against the request's User-Agent header with
+	// "*" as a free-form wildcard, e.g. "Prometheus/*" matches
+	// "Prometheus/2.45.0/linux". This is deliberately not path.Match: a
+	// User-Agent has no path separators to stop a "*" at, so "/" needs to
+	// match like anything else.
+	UserAgent string
+	Origin    string
+	Match     func(*http.Request) bool
+}
+
+func (rule *ExemptionRule) matches(r *http.Request) bool {
+	if rule.Match != nil {
+		return rule.Match(r)
+	}
+
+	if rule.CIDR != nil {
+		ip := net.ParseIP(getIP(r))
+		return ip != nil && rule.CIDR.Contains(ip)
+	}
+
+	if rule.UserAgent != "" {
+		return userAgentMatch(rule.UserAgent, r.UserAgent())
+	}
+
+	if rule.Origin != "" {
+		return r.Header.Get("Origin") == rule.Origin
+	}
+
+	return false
+}
+
+// userAgentMatch reports whether s matches pattern, where "*" matches any
+// run of characters, including "/" - unlike path.Match, which treats "/" as
+// a segment separator a "*" cannot cross.
+func userAgentMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return s == pattern
+	}
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+func isExempt(config *RateLimiterConfig, r *http.Request) bool {
+	for _, rule := range config.Exemptions {
+		if rule.matches(r) {
+			DebugPrintfWithoutKey(config, "request to \"%s\" exempted from rate limiting", r.RequestURI)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,167 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestRequest(t *testing.T, userAgent string, origin string) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if userAgent != "" {
+		r.Header.Set("User-Agent", userAgent)
+	}
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+
+	return r
+}
+
+func TestUserAgentMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		ua      string
+		want    bool
+	}{
+		{"exact match, no wildcard", "HealthChecker", "HealthChecker", true},
+		{"exact mismatch, no wildcard", "HealthChecker", "HealthChecker/1.0", false},
+		{"trailing wildcard", "HealthChecker/*", "HealthChecker/1.0", true},
+		{"trailing wildcard matches multiple segments", "Prometheus/*", "Prometheus/2.45.0/linux", true},
+		{"trailing wildcard requires the prefix", "Prometheus/*", "curl/8.0", false},
+		{"wildcard in the middle", "Mozilla/*/Chrome", "Mozilla/5.0/Chrome", true},
+		{"wildcard in the middle, no match", "Mozilla/*/Chrome", "Mozilla/5.0/Firefox", false},
+		{"bare wildcard matches anything", "*", "anything at all", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userAgentMatch(tt.pattern, tt.ua); got != tt.want {
+				t.Errorf("userAgentMatch(%q, %q) = %t, want %t", tt.pattern, tt.ua, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExemptionRule_Matches_UserAgent(t *testing.T) {
+	rule := &ExemptionRule{UserAgent: "Prometheus/*"}
+
+	r := newTestRequest(t, "Prometheus/2.45.0/linux", "")
+	if !rule.matches(r) {
+		t.Fatal("expected a multi-segment Prometheus user agent to match")
+	}
+}
+
+func TestExemptionRule_Matches_CIDR(t *testing.T) {
+	_, ipNet, err := net.ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("failed to parse test CIDR: %s", err)
+	}
+	rule := &ExemptionRule{CIDR: ipNet}
+
+	r := newTestRequest(t, "", "")
+	r.RemoteAddr = "192.0.2.1:1234"
+	if !rule.matches(r) {
+		t.Fatal("expected an IP inside the CIDR to match")
+	}
+
+	r.RemoteAddr = "203.0.113.1:1234"
+	if rule.matches(r) {
+		t.Fatal("expected an IP outside the CIDR not to match")
+	}
+}
+
+func TestExemptionRule_Matches_Origin(t *testing.T) {
+	rule := &ExemptionRule{Origin: "https://internal.example.com"}
+
+	r := newTestRequest(t, "", "https://internal.example.com")
+	if !rule.matches(r) {
+		t.Fatal("expected matching Origin header to match")
+	}
+
+	r = newTestRequest(t, "", "https://untrusted.example.com")
+	if rule.matches(r) {
+		t.Fatal("expected mismatched Origin header not to match")
+	}
+}
+
+func TestConfigureExemptions_ParsesEnvCIDRsAndUserAgents(t *testing.T) {
+	t.Setenv(envExemptCIDRs, "192.0.2.0/24, not-a-cidr, 198.51.100.0/24")
+	t.Setenv(envExemptUserAgents, "Prometheus/*, HealthChecker")
+
+	config := &RateLimiterConfig{}
+	configureExemptions(config)
+
+	var cidrs, userAgents int
+	for _, rule := range config.Exemptions {
+		switch {
+		case rule.CIDR != nil:
+			cidrs++
+		case rule.UserAgent != "":
+			userAgents++
+		}
+	}
+
+	if cidrs != 2 {
+		t.Fatalf("expected the 2 valid CIDRs to produce an exemption each and the invalid one to be skipped, got %d CIDR rules", cidrs)
+	}
+	if userAgents != 2 {
+		t.Fatalf("expected 2 UserAgent exemptions, got %d", userAgents)
+	}
+}
+
+func TestConfigureExemptions_DisabledWhenDisableEnvsSet(t *testing.T) {
+	t.Setenv(envExemptCIDRs, "192.0.2.0/24")
+
+	config := &RateLimiterConfig{DisableEnvs: true}
+	configureExemptions(config)
+
+	if len(config.Exemptions) != 0 {
+		t.Fatalf("expected no exemptions with DisableEnvs set, got %d", len(config.Exemptions))
+	}
+}
+
+func TestIsExempt_LogsWhenDebugEnabled(t *testing.T) {
+	config := &RateLimiterConfig{Debug: true, Exemptions: []ExemptionRule{{UserAgent: "HealthChecker"}}}
+	r := newTestRequest(t, "HealthChecker", "")
+
+	output := captureStdout(t, func() {
+		if !isExempt(config, r) {
+			t.Fatal("expected the matching UserAgent rule to exempt the request")
+		}
+	})
+
+	if !strings.Contains(output, "exempted from rate limiting") {
+		t.Fatalf("expected the debug log to mention the exemption, got %q", output)
+	}
+}
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	original := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	os.Stdout = w
+
+	fn()
+
+	w.Close()
+	os.Stdout = original
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("failed to read captured stdout: %s", err)
+	}
+
+	return buf.String()
+}
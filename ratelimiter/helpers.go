@@ -0,0 +1,49 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func getStringEnv(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+func getInt64Env(key string) (int64, bool) {
+	value, ok := getStringEnv(key)
+	if !ok {
+		return 0, false
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return parsed, true
+}
+
+func getBoolEnv(key string) (bool, bool) {
+	value, ok := getStringEnv(key)
+	if !ok {
+		return false, false
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, false
+	}
+
+	return parsed, true
+}
+
+func DebugPrintfWithoutKey(config *RateLimiterConfig, format string, args ...interface{}) {
+	if config.Debug {
+		fmt.Printf(format+"\n", args...)
+	}
+}
@@ -0,0 +1,150 @@
+package ratelimiter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// IdentityExtractor resolves an authenticated identity from a request, so
+// it can be rate limited independently from the client IP. tier selects
+// which entry of RateLimiterConfig.Tiers applies to id; ok is false when
+// the request carries no identity (e.g. anonymous traffic), in which case
+// it is left to the IP limiter alone.
+type IdentityExtractor interface {
+	Extract(r *http.Request) (id string, tier string, ok bool)
+}
+
+// JWTIdentityExtractor reads a bearer token from Header and decodes its
+// claims to read SubjectClaim/TierClaim from it. Claim paths are
+// dot-separated, e.g. "user.id".
+//
+// Without Verify set, claims are trusted as-is: the payload is just
+// base64-decoded, the same way anyone can read a JWT's claims without the
+// signing key. Since those claims feed straight into the Tiers lookup, a
+// client could self-mint a token with an arbitrary sub and "tier": "premium"
+// to land a bigger bucket or rotate identities to dodge the limiter
+// entirely. Only skip Verify when something upstream (a gateway, an auth
+// middleware earlier in the chain) has already validated the token's
+// signature.
+type JWTIdentityExtractor struct {
+	Header       string
+	SubjectClaim string
+	TierClaim    string
+	// Verify, when set, validates the raw bearer token (signature,
+	// expiry, issuer, whatever the caller needs checked) before its claims
+	// are trusted. A non-nil error is treated the same as a missing token.
+	Verify func(token string) error
+}
+
+func NewJWTIdentityExtractor() *JWTIdentityExtractor {
+	return &JWTIdentityExtractor{Header: "Authorization", SubjectClaim: "sub", TierClaim: "tier"}
+}
+
+func (e *JWTIdentityExtractor) Extract(r *http.Request) (string, string, bool) {
+	value := r.Header.Get(e.Header)
+
+	token := strings.TrimPrefix(value, "Bearer ")
+	if token == "" {
+		return "", "", false
+	}
+
+	if e.Verify != nil {
+		if err := e.Verify(token); err != nil {
+			return "", "", false
+		}
+	}
+
+	claims, ok := decodeJWTClaims(token)
+	if !ok {
+		return "", "", false
+	}
+
+	id, ok := claimString(claims, e.SubjectClaim)
+	if !ok {
+		return "", "", false
+	}
+
+	tier, _ := claimString(claims, e.TierClaim)
+
+	return id, tier, true
+}
+
+func decodeJWTClaims(token string) (map[string]interface{}, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, false
+	}
+
+	return claims, true
+}
+
+func claimString(claims map[string]interface{}, path string) (string, bool) {
+	var current interface{} = claims
+
+	for _, part := range strings.Split(path, ".") {
+		asMap, ok := current.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+
+		current, ok = asMap[part]
+		if !ok {
+			return "", false
+		}
+	}
+
+	value, ok := current.(string)
+	return value, ok
+}
+
+// BasicAuthIdentityExtractor identifies requests by their HTTP Basic Auth
+// username. Tier, when set, is applied to every identity it resolves.
+type BasicAuthIdentityExtractor struct {
+	Tier string
+}
+
+func NewBasicAuthIdentityExtractor() *BasicAuthIdentityExtractor {
+	return &BasicAuthIdentityExtractor{}
+}
+
+func (e *BasicAuthIdentityExtractor) Extract(r *http.Request) (string, string, bool) {
+	username, _, ok := r.BasicAuth()
+	if !ok || username == "" {
+		return "", "", false
+	}
+
+	return username, e.Tier, true
+}
+
+// SessionIdentityExtractor reads a session id from CookieName and resolves
+// it to an identity/tier pair through a user-supplied Lookup callback,
+// e.g. backed by a session store.
+type SessionIdentityExtractor struct {
+	CookieName string
+	Lookup     func(sessionID string) (id string, tier string, ok bool)
+}
+
+func NewSessionIdentityExtractor(cookieName string, lookup func(sessionID string) (string, string, bool)) *SessionIdentityExtractor {
+	return &SessionIdentityExtractor{CookieName: cookieName, Lookup: lookup}
+}
+
+func (e *SessionIdentityExtractor) Extract(r *http.Request) (string, string, bool) {
+	cookie, err := r.Cookie(e.CookieName)
+	if err != nil || cookie.Value == "" {
+		return "", "", false
+	}
+
+	return e.Lookup(cookie.Value)
+}
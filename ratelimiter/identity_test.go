@@ -0,0 +1,188 @@
+package ratelimiter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func makeJWT(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+
+	payloadJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %s", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	return header + "." + payload + ".sig"
+}
+
+func TestJWTIdentityExtractor_Extract(t *testing.T) {
+	e := NewJWTIdentityExtractor()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+makeJWT(t, map[string]interface{}{"sub": "user-1", "tier": "premium"}))
+
+	id, tier, ok := e.Extract(r)
+	if !ok {
+		t.Fatal("expected ok=true")
+	}
+	if id != "user-1" {
+		t.Errorf("id = %q, want %q", id, "user-1")
+	}
+	if tier != "premium" {
+		t.Errorf("tier = %q, want %q", tier, "premium")
+	}
+}
+
+func TestJWTIdentityExtractor_Extract_NestedClaimPath(t *testing.T) {
+	e := NewJWTIdentityExtractor()
+	e.SubjectClaim = "user.id"
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+makeJWT(t, map[string]interface{}{
+		"user": map[string]interface{}{"id": "nested-1"},
+	}))
+
+	id, _, ok := e.Extract(r)
+	if !ok || id != "nested-1" {
+		t.Fatalf("got id=%q ok=%t, want id=%q ok=true", id, ok, "nested-1")
+	}
+}
+
+func TestJWTIdentityExtractor_Extract_MissingSubjectOrMalformedToken(t *testing.T) {
+	e := NewJWTIdentityExtractor()
+
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"missing header", ""},
+		{"missing subject claim", "Bearer " + makeJWT(t, map[string]interface{}{"tier": "premium"})},
+		{"malformed token", "Bearer not-a-jwt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			if _, _, ok := e.Extract(r); ok {
+				t.Fatal("expected ok=false")
+			}
+		})
+	}
+}
+
+// The "Bearer " prefix is only trimmed, not required: TrimPrefix is a no-op
+// on a header that lacks it, so a bare token still works. Documented here
+// so the behavior doesn't get "fixed" into a breaking change by accident.
+func TestJWTIdentityExtractor_Extract_BearerPrefixIsOptional(t *testing.T) {
+	e := NewJWTIdentityExtractor()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", makeJWT(t, map[string]interface{}{"sub": "user-1"}))
+
+	id, _, ok := e.Extract(r)
+	if !ok || id != "user-1" {
+		t.Fatalf("got id=%q ok=%t, want id=user-1 ok=true", id, ok)
+	}
+}
+
+func TestJWTIdentityExtractor_Extract_VerifyHookRejectsToken(t *testing.T) {
+	e := NewJWTIdentityExtractor()
+	e.Verify = func(token string) error { return errors.New("bad signature") }
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+makeJWT(t, map[string]interface{}{"sub": "user-1"}))
+
+	if _, _, ok := e.Extract(r); ok {
+		t.Fatal("expected Extract to fail when Verify rejects the token")
+	}
+}
+
+func TestJWTIdentityExtractor_Extract_VerifyHookReceivesRawToken(t *testing.T) {
+	e := NewJWTIdentityExtractor()
+
+	token := makeJWT(t, map[string]interface{}{"sub": "user-1"})
+
+	var seen string
+	e.Verify = func(t string) error {
+		seen = t
+		return nil
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	if _, _, ok := e.Extract(r); !ok {
+		t.Fatal("expected ok=true once Verify accepts the token")
+	}
+	if seen != token {
+		t.Errorf("Verify saw %q, want the raw token %q", seen, token)
+	}
+}
+
+func TestBasicAuthIdentityExtractor_Extract(t *testing.T) {
+	e := NewBasicAuthIdentityExtractor()
+	e.Tier = "gold"
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth("alice", "s3cret")
+
+	id, tier, ok := e.Extract(r)
+	if !ok || id != "alice" || tier != "gold" {
+		t.Fatalf("got id=%q tier=%q ok=%t, want id=alice tier=gold ok=true", id, tier, ok)
+	}
+}
+
+func TestBasicAuthIdentityExtractor_Extract_NoCredentials(t *testing.T) {
+	e := NewBasicAuthIdentityExtractor()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if _, _, ok := e.Extract(r); ok {
+		t.Fatal("expected ok=false when the request carries no basic auth")
+	}
+}
+
+func TestSessionIdentityExtractor_Extract(t *testing.T) {
+	e := NewSessionIdentityExtractor("session_id", func(sessionID string) (string, string, bool) {
+		if sessionID != "abc123" {
+			return "", "", false
+		}
+		return "user-1", "gold", true
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: "abc123"})
+
+	id, tier, ok := e.Extract(r)
+	if !ok || id != "user-1" || tier != "gold" {
+		t.Fatalf("got id=%q tier=%q ok=%t, want id=user-1 tier=gold ok=true", id, tier, ok)
+	}
+}
+
+func TestSessionIdentityExtractor_Extract_NoCookieOrUnknownSession(t *testing.T) {
+	e := NewSessionIdentityExtractor("session_id", func(sessionID string) (string, string, bool) {
+		return "", "", false
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, _, ok := e.Extract(r); ok {
+		t.Fatal("expected ok=false when the request carries no session cookie")
+	}
+
+	r.AddCookie(&http.Cookie{Name: "session_id", Value: "unknown"})
+	if _, _, ok := e.Extract(r); ok {
+		t.Fatal("expected ok=false when Lookup does not recognize the session")
+	}
+}
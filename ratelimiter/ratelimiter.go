@@ -0,0 +1,142 @@
+package ratelimiter
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/adapter"
+)
+
+// RateLimitSmoothingEvent is emitted whenever a request is allowed only
+// because it dipped into burst capacity instead of the steady refill rate.
+type RateLimitSmoothingEvent struct {
+	Key       string
+	Remaining int64
+}
+
+// NewRateLimiterWithConfig builds a chi-style middleware that rate limits
+// requests by IP and, independently, by whatever identity the request
+// carries (an API_KEY header, or config.Identity). A request is blocked as
+// soon as either bucket trips, whichever happens first.
+func NewRateLimiterWithConfig(config *RateLimiterConfig) func(http.Handler) http.Handler {
+	config = setConfiguration(config)
+
+	if config.ConfigSource != nil {
+		startConfigSourceSubscription(config)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isExempt(config, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ipKey := "ip:" + getIP(r)
+			ipResult := config.take(ipKey, config.getIP())
+
+			identityKey, identityResult := config.takeIdentity(r)
+
+			if identityResult != nil {
+				DebugPrintfWithoutKey(config, "ip \"%s\": remaining=%d allowed=%t; identity \"%s\": remaining=%d allowed=%t", ipKey, ipResult.Remaining, ipResult.Allowed, identityKey, identityResult.Remaining, identityResult.Allowed)
+			} else {
+				DebugPrintfWithoutKey(config, "ip \"%s\": remaining=%d allowed=%t", ipKey, ipResult.Remaining, ipResult.Allowed)
+			}
+
+			if !ipResult.Allowed {
+				DebugPrintfWithoutKey(config, "blocking key \"%s\": no tokens remaining", ipKey)
+				config.ResponseWriter.WriteBlockedResponse(w, r, ipResult)
+				return
+			}
+
+			if identityResult != nil && !identityResult.Allowed {
+				DebugPrintfWithoutKey(config, "blocking key \"%s\": no tokens remaining", identityKey)
+				config.ResponseWriter.WriteBlockedResponse(w, r, identityResult)
+				return
+			}
+
+			if config.EmitHeadersOnSuccess {
+				primaryResult := ipResult
+				if identityResult != nil {
+					primaryResult = identityResult
+				}
+				config.ResponseWriter.WriteAllowedHeaders(w, primaryResult)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take runs the storage adapter for key/rateConfig, firing the smoothing
+// hook when the request only went through because of burst capacity. A
+// storage error is treated as "allowed", so an adapter outage degrades the
+// rate limiter instead of taking the whole service down with it.
+func (c *RateLimiterConfig) take(key string, rateConfig *RateLimiterRateConfig) *adapter.LimitResult {
+	result, err := c.StorageAdapter.Take(key, rateConfig.MaxRequestsPerSecond, rateConfig.BurstSize, rateConfig.BlockTimeMilliseconds)
+	if err != nil {
+		DebugPrintfWithoutKey(c, "error taking token for key \"%s\": %s", key, err)
+		return &adapter.LimitResult{Allowed: true}
+	}
+
+	if result.Smoothed && c.OnRateLimitSmoothing != nil {
+		c.OnRateLimitSmoothing(RateLimitSmoothingEvent{Key: key, Remaining: result.Remaining})
+	}
+
+	return result
+}
+
+// takeIdentity resolves the request's identity - the API_KEY header, or
+// failing that config.Identity - and takes a token from its bucket. It
+// returns a nil result when the request carries no identity at all, in
+// which case only the IP bucket applies.
+func (c *RateLimiterConfig) takeIdentity(r *http.Request) (string, *adapter.LimitResult) {
+	if token := r.Header.Get("API_KEY"); token != "" {
+		rateConfig, _ := c.GetRateLimiterRateConfigForToken(token)
+		key := "token:" + token
+		return key, c.take(key, rateConfig)
+	}
+
+	if c.Identity == nil {
+		return "", nil
+	}
+
+	id, tier, ok := c.Identity.Extract(r)
+	if !ok {
+		return "", nil
+	}
+
+	rateConfig, ok := c.Tiers[tier]
+	if !ok || rateConfig == nil {
+		rateConfig = c.getToken()
+	}
+
+	key := "identity:" + id
+	return key, c.take(key, rateConfig)
+}
+
+// startConfigSourceSubscription subscribes to config.ConfigSource in the
+// background and hot-swaps IP, Token and CustomTokens as new snapshots
+// arrive, for as long as the process runs.
+func startConfigSourceSubscription(config *RateLimiterConfig) {
+	snapshots, err := config.ConfigSource.Subscribe(context.Background())
+	if err != nil {
+		DebugPrintfWithoutKey(config, "failed to subscribe to config source: %s", err)
+		return
+	}
+
+	go func() {
+		for snapshot := range snapshots {
+			config.applyConfigSourceSnapshot(snapshot)
+		}
+	}()
+}
+
+func getIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
@@ -0,0 +1,161 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newLimiterRequest(remoteAddr string, apiKey string, userAgent string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		r.Header.Set("API_KEY", apiKey)
+	}
+	if userAgent != "" {
+		r.Header.Set("User-Agent", userAgent)
+	}
+	return r
+}
+
+func passThroughHandler() (http.Handler, *int) {
+	calls := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}), &calls
+}
+
+func TestNewRateLimiterWithConfig_SmoothingHookFiresOnBurstDip(t *testing.T) {
+	var events []RateLimitSmoothingEvent
+
+	handler, calls := passThroughHandler()
+	limiter := NewRateLimiterWithConfig(&RateLimiterConfig{
+		DisableEnvs: true,
+		IP:          &RateLimiterRateConfig{MaxRequestsPerSecond: 1, BurstSize: 3, BlockTimeMilliseconds: 1000},
+		OnRateLimitSmoothing: func(e RateLimitSmoothingEvent) {
+			events = append(events, e)
+		},
+	})(handler)
+
+	r := newLimiterRequest("203.0.113.1:1234", "", "")
+
+	// First request only dips one token below full capacity (3 -> 2),
+	// which is not yet smoothing per memory.Take's "remaining < capacity-1"
+	// check; the second dips to 1, which is.
+	limiter.ServeHTTP(httptest.NewRecorder(), r)
+	limiter.ServeHTTP(httptest.NewRecorder(), r)
+
+	if *calls != 2 {
+		t.Fatalf("expected both requests to reach the handler, got %d calls", *calls)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected OnRateLimitSmoothing to fire exactly once, got %d events: %+v", len(events), events)
+	}
+	if events[0].Key != "ip:203.0.113.1" {
+		t.Fatalf("expected the smoothing event to carry the IP bucket key, got %q", events[0].Key)
+	}
+}
+
+func TestNewRateLimiterWithConfig_ExemptionBypassesRateLimit(t *testing.T) {
+	handler, calls := passThroughHandler()
+	limiter := NewRateLimiterWithConfig(&RateLimiterConfig{
+		DisableEnvs: true,
+		IP:          &RateLimiterRateConfig{MaxRequestsPerSecond: 1, BlockTimeMilliseconds: 1000},
+		Exemptions:  []ExemptionRule{{UserAgent: "HealthChecker"}},
+	})(handler)
+
+	r := newLimiterRequest("203.0.113.2:1234", "", "HealthChecker")
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		limiter.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected exempted traffic to always reach the handler, got status %d", i, w.Code)
+		}
+	}
+
+	if *calls != 5 {
+		t.Fatalf("expected all 5 exempted requests to reach the handler, got %d calls", *calls)
+	}
+}
+
+func TestNewRateLimiterWithConfig_BlocksOnIPBeforeIdentity(t *testing.T) {
+	handler, calls := passThroughHandler()
+	limiter := NewRateLimiterWithConfig(&RateLimiterConfig{
+		DisableEnvs: true,
+		IP:          &RateLimiterRateConfig{MaxRequestsPerSecond: 1, BlockTimeMilliseconds: 1000},
+		Token:       &RateLimiterRateConfig{MaxRequestsPerSecond: 5, BlockTimeMilliseconds: 1000},
+	})(handler)
+
+	r := newLimiterRequest("203.0.113.3:1234", "trusted-token", "")
+
+	w := httptest.NewRecorder()
+	limiter.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the first request to be allowed, got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	limiter.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the second request to be blocked once the IP bucket (capacity 1) is exhausted, got status %d", w.Code)
+	}
+	if limit := w.Header().Get("RateLimit-Limit"); limit != "1" {
+		t.Fatalf("expected the blocked response to report the IP bucket's limit (1), since IP is checked before identity, got %q", limit)
+	}
+
+	if *calls != 1 {
+		t.Fatalf("expected only the first request to reach the handler, got %d calls", *calls)
+	}
+}
+
+func TestNewRateLimiterWithConfig_BlocksOnIdentityWhenIPHasHeadroom(t *testing.T) {
+	handler, calls := passThroughHandler()
+	limiter := NewRateLimiterWithConfig(&RateLimiterConfig{
+		DisableEnvs: true,
+		IP:          &RateLimiterRateConfig{MaxRequestsPerSecond: 5, BlockTimeMilliseconds: 1000},
+		Token:       &RateLimiterRateConfig{MaxRequestsPerSecond: 1, BlockTimeMilliseconds: 1000},
+	})(handler)
+
+	r := newLimiterRequest("203.0.113.4:1234", "trusted-token", "")
+
+	w := httptest.NewRecorder()
+	limiter.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the first request to be allowed, got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	limiter.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the second request to be blocked once the identity bucket (capacity 1) is exhausted, got status %d", w.Code)
+	}
+	if limit := w.Header().Get("RateLimit-Limit"); limit != "1" {
+		t.Fatalf("expected the blocked response to report the identity bucket's limit (1), got %q", limit)
+	}
+
+	if *calls != 1 {
+		t.Fatalf("expected only the first request to reach the handler, got %d calls", *calls)
+	}
+}
+
+func TestNewRateLimiterWithConfig_EmitHeadersOnSuccess(t *testing.T) {
+	handler, _ := passThroughHandler()
+	limiter := NewRateLimiterWithConfig(&RateLimiterConfig{
+		DisableEnvs:          true,
+		IP:                   &RateLimiterRateConfig{MaxRequestsPerSecond: 5, BlockTimeMilliseconds: 1000},
+		EmitHeadersOnSuccess: true,
+	})(handler)
+
+	r := newLimiterRequest("203.0.113.5:1234", "", "")
+	w := httptest.NewRecorder()
+	limiter.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the request to be allowed, got status %d", w.Code)
+	}
+	if w.Header().Get("RateLimit-Limit") == "" {
+		t.Fatal("expected EmitHeadersOnSuccess to write RateLimit-* headers on an allowed request")
+	}
+}
@@ -0,0 +1,98 @@
+package responsewriter
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/adapter"
+)
+
+// RateLimiterResponseWriter is responsible for writing the HTTP response
+// sent back to a client, based on the outcome of a rate limit check.
+type RateLimiterResponseWriter interface {
+	// WriteBlockedResponse writes the response for a request blocked by
+	// the rate limiter.
+	WriteBlockedResponse(w http.ResponseWriter, r *http.Request, result *adapter.LimitResult)
+	// WriteAllowedHeaders writes the RateLimit-* headers onto a request
+	// that was allowed through. Only called when
+	// RateLimiterConfig.EmitHeadersOnSuccess is set.
+	WriteAllowedHeaders(w http.ResponseWriter, result *adapter.LimitResult)
+}
+
+// RateLimiterDefaultResponseWriterOption configures a
+// RateLimiterDefaultResponseWriter.
+type RateLimiterDefaultResponseWriterOption func(*RateLimiterDefaultResponseWriter)
+
+// WithAppData attaches app-supplied context to the "data" field of the
+// default 429 body, alongside limit/window/retryAfter.
+func WithAppData(appData func(r *http.Request) interface{}) RateLimiterDefaultResponseWriterOption {
+	return func(rw *RateLimiterDefaultResponseWriter) { rw.appData = appData }
+}
+
+// RateLimiterDefaultResponseWriter is the RateLimiterResponseWriter used
+// when no other implementation is configured. It emits the IETF
+// RateLimit-Limit/RateLimit-Remaining/RateLimit-Reset headers and, on a
+// block, a Retry-After header plus a JSON-RPC-style error body.
+type RateLimiterDefaultResponseWriter struct {
+	appData func(r *http.Request) interface{}
+}
+
+func NewRateLimiterDefaultResponseWriter(opts ...RateLimiterDefaultResponseWriterOption) *RateLimiterDefaultResponseWriter {
+	rw := &RateLimiterDefaultResponseWriter{}
+
+	for _, opt := range opts {
+		opt(rw)
+	}
+
+	return rw
+}
+
+type blockedResponseData struct {
+	Limit      int64       `json:"limit"`
+	Window     string      `json:"window"`
+	RetryAfter int64       `json:"retryAfter"`
+	App        interface{} `json:"app,omitempty"`
+}
+
+type blockedResponseBody struct {
+	Code    int                 `json:"code"`
+	Message string              `json:"message"`
+	Data    blockedResponseData `json:"data"`
+}
+
+func (rw *RateLimiterDefaultResponseWriter) WriteBlockedResponse(w http.ResponseWriter, r *http.Request, result *adapter.LimitResult) {
+	writeRateLimitHeaders(w, result)
+
+	retryAfterSeconds := int64(math.Ceil(float64(result.ResetMs) / 1000))
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds, 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	data := blockedResponseData{
+		Limit:      result.Limit,
+		Window:     "1s",
+		RetryAfter: result.ResetMs,
+	}
+
+	if rw.appData != nil {
+		data.App = rw.appData(r)
+	}
+
+	json.NewEncoder(w).Encode(blockedResponseBody{
+		Code:    http.StatusTooManyRequests,
+		Message: "rate limited",
+		Data:    data,
+	})
+}
+
+func (rw *RateLimiterDefaultResponseWriter) WriteAllowedHeaders(w http.ResponseWriter, result *adapter.LimitResult) {
+	writeRateLimitHeaders(w, result)
+}
+
+func writeRateLimitHeaders(w http.ResponseWriter, result *adapter.LimitResult) {
+	w.Header().Set("RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+	w.Header().Set("RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	w.Header().Set("RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(result.ResetMs)/1000)), 10))
+}
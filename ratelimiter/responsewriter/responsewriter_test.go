@@ -0,0 +1,62 @@
+package responsewriter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arfurlaneto/fullcycle-sonarcloud-challenge/ratelimiter/adapter"
+)
+
+func TestRateLimiterDefaultResponseWriter_WriteBlockedResponse(t *testing.T) {
+	rw := NewRateLimiterDefaultResponseWriter()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rw.WriteBlockedResponse(w, r, &adapter.LimitResult{Allowed: false, Remaining: 0, ResetMs: 1500, Limit: 10})
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "10" {
+		t.Errorf("RateLimit-Limit = %q, want %q", got, "10")
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want %q", got, "0")
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "2" {
+		t.Errorf("RateLimit-Reset = %q, want %q", got, "2")
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want %q", got, "2")
+	}
+}
+
+func TestRateLimiterDefaultResponseWriter_WriteBlockedResponse_WithAppData(t *testing.T) {
+	rw := NewRateLimiterDefaultResponseWriter(WithAppData(func(r *http.Request) interface{} {
+		return map[string]string{"path": r.URL.Path}
+	}))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+
+	rw.WriteBlockedResponse(w, r, &adapter.LimitResult{Allowed: false, Limit: 10})
+
+	if !strings.Contains(w.Body.String(), `"path":"/orders"`) {
+		t.Fatalf("expected response body to include app data, got %s", w.Body.String())
+	}
+}
+
+func TestRateLimiterDefaultResponseWriter_WriteAllowedHeaders(t *testing.T) {
+	rw := NewRateLimiterDefaultResponseWriter()
+	w := httptest.NewRecorder()
+
+	rw.WriteAllowedHeaders(w, &adapter.LimitResult{Allowed: true, Remaining: 4, Limit: 5})
+
+	if got := w.Header().Get("RateLimit-Remaining"); got != "4" {
+		t.Errorf("RateLimit-Remaining = %q, want %q", got, "4")
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("expected no Retry-After header on an allowed request, got %q", got)
+	}
+}